@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec encodes and decodes arbitrary Go values to and from the strings
+// a Tx actually stores, so SetObject/GetObject are not limited to plain
+// strings the way Set/Get are.
+type Codec interface {
+	Name() string
+	Marshal(v any) (string, error)
+	Unmarshal(data string, dst any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (jsonCodec) Unmarshal(data string, dst any) error {
+	return json.Unmarshal([]byte(data), dst)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (gobCodec) Unmarshal(data string, dst any) error {
+	return gob.NewDecoder(bytes.NewReader([]byte(data))).Decode(dst)
+}
+
+// JSONCodec, GobCodec and MsgpackCodec are registered out of the box.
+// RegisterCodec lets a caller plug in any other one without any DB API
+// change.
+var (
+	JSONCodec    Codec = jsonCodec{}
+	GobCodec     Codec = gobCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+var codecs = map[string]Codec{
+	JSONCodec.Name():    JSONCodec,
+	GobCodec.Name():     GobCodec,
+	MsgpackCodec.Name(): MsgpackCodec,
+}
+
+// RegisterCodec makes codec available to SetCodec/SetObject/GetObject
+// under codec.Name().
+func RegisterCodec(codec Codec) {
+	codecs[codec.Name()] = codec
+}
+
+func codecByName(name string) (Codec, error) {
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("db: unknown codec %q", name)
+	}
+	return codec, nil
+}
+
+// SetCodec selects which registered codec SetObject/GetObject use for
+// db. It defaults to "json".
+func (db *DB) SetCodec(name string) error {
+	if _, err := codecByName(name); err != nil {
+		return err
+	}
+	db.codec = name
+	return nil
+}
+
+// SetObject encodes v with tx's DB's configured codec and stores it
+// under key the same way Set would.
+func (tx *Tx) SetObject(key string, v any) error {
+	codec, err := codecByName(tx.db.codec)
+	if err != nil {
+		return err
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("db: encode %s: %w", key, err)
+	}
+	tx.Set(key, data)
+	return nil
+}
+
+// GetObject decodes the value stored at key into dst using tx's DB's
+// configured codec.
+func (tx *Tx) GetObject(key string, dst any) error {
+	codec, err := codecByName(tx.db.codec)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(tx.Get(key), dst)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// effectiveTimeout folds a Tx's requested LockOptions.Timeout and ctx's
+// deadline into a single duration to hand to a Locker, which does not
+// itself understand context.Context yet; this is the seam a future
+// context-aware distributed locking backend would replace.
+func effectiveTimeout(ctx context.Context, opts LockOptions) time.Duration {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if until := time.Until(deadline); until < timeout {
+			timeout = until
+		}
+	}
+	return timeout
+}
+
+// lockCtx is lock, but gives up and returns ctx.Err() if ctx is done
+// before the lock is won. A View takes no mutex at all so there is
+// nothing to race; a writable Tx races mu.Lock() against ctx.Done() in a
+// helper goroutine, and if ctx wins, the helper releases the mutex on
+// our behalf once it eventually does acquire it so the lock is never
+// left held by an abandoned Tx. Whatever lockCtx itself already
+// acquired — the distributed lock, the deadlock tracker's bookkeeping —
+// is released before any error return, since the caller never gets a
+// *Tx to unlock() on a failed Begin.
+func (tx *Tx) lockCtx(ctx context.Context, opts LockOptions) error {
+	if tx.db.locker != nil {
+		handle, err := tx.db.locker.GetLock(tx.writable, effectiveTimeout(ctx, opts))
+		if err != nil {
+			return err
+		}
+		tx.lockHandle = handle
+		if ctx.Err() != nil {
+			tx.db.locker.ReleaseLock(tx.lockHandle)
+			return ctx.Err()
+		}
+	}
+	tx.db.beginTracking(tx.writable)
+
+	if !tx.writable {
+		return nil
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		tx.db.mu.Lock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		tx.db.endTracking()
+		if tx.db.locker != nil {
+			tx.db.locker.ReleaseLock(tx.lockHandle)
+		}
+		go func() {
+			<-acquired
+			tx.db.mu.Unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+// BeginCtx is Begin with cancellation: it returns ctx.Err() instead of
+// blocking forever if ctx is done before the lock is acquired.
+func (db *DB) BeginCtx(ctx context.Context, writable bool) (*Tx, error) {
+	return db.BeginCtxWithOptions(ctx, writable, LockOptions{})
+}
+
+// BeginCtxWithOptions is BeginCtx plus the distributed-locking tuning
+// from BeginWithOptions.
+func (db *DB) BeginCtxWithOptions(ctx context.Context, writable bool, opts LockOptions) (*Tx, error) {
+	tx := &Tx{
+		db:       db,
+		writable: writable,
+		bucket:   defaultBucket,
+	}
+	if err := tx.lockCtx(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	tx.snapshot = db.engine.Snapshot()
+	if _, ok := tx.snapshot[defaultBucket]; !ok {
+		tx.snapshot[defaultBucket] = make(map[string]string)
+	}
+
+	return tx, nil
+}
+
+// ViewCtx is View with cancellation.
+func (db *DB) ViewCtx(ctx context.Context, fn func(tx *Tx) error) error {
+	return db.managedCtx(ctx, false, fn)
+}
+
+// UpdateCtx is Update with cancellation.
+func (db *DB) UpdateCtx(ctx context.Context, fn func(tx *Tx) error) error {
+	return db.managedCtx(ctx, true, fn)
+}
+
+// managedCtx is managed, but honors ctx both while waiting for the lock
+// and while fn runs: if ctx fires first, it returns ctx.Err() rather
+// than waiting for fn to finish on its own. fn itself keeps running in
+// the background until it returns, same as any context-cancelled
+// goroutine in Go — cancellation asks it to stop, it does not force it to.
+func (db *DB) managedCtx(ctx context.Context, writable bool, fn func(tx *Tx) error) (err error) {
+	var tx *Tx
+	tx, err = db.BeginCtx(ctx, writable)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if !writable || err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- fn(tx) }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingLocker's GetLock always succeeds immediately, so lockCtx moves
+// on to racing the in-process mutex; the point of this test is to check
+// how BeginCtx behaves once it's holding a distributed lock and then
+// loses that race.
+type countingLocker struct {
+	released chan any
+}
+
+func (l *countingLocker) GetLock(writable bool, timeout time.Duration) (any, error) {
+	return "handle", nil
+}
+
+func (l *countingLocker) ReleaseLock(handle any) {
+	l.released <- handle
+}
+
+// TestBeginCtxReleasesDistributedLockOnCancel reproduces a leak where a
+// Locker's GetLock had already succeeded, but losing the mutex race to
+// ctx.Done() returned ctx.Err() without ever calling ReleaseLock.
+func TestBeginCtxReleasesDistributedLockOnCancel(t *testing.T) {
+	locker := &countingLocker{released: make(chan any, 1)}
+	db := CreateWithLocker(locker)
+
+	// Hold the in-process mutex with a long-running writable Tx so the
+	// BeginCtx below loses the race against ctx.Done().
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go db.Update(func(tx *Tx) error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := db.BeginCtx(ctx, true)
+	if err == nil {
+		t.Fatalf("expected BeginCtx to be cancelled")
+	}
+
+	select {
+	case <-locker.released:
+	case <-time.After(time.Second):
+		t.Fatalf("distributed lock was never released after BeginCtx was cancelled")
+	}
+}
+
+// TestBeginCtxUntracksOnCancel reproduces a leak where a cancelled
+// BeginCtx left a phantom "holds db" entry in the deadlock tracker,
+// since beginTracking ran before the mutex-vs-ctx race but nothing ever
+// called endTracking on the cancellation path.
+func TestBeginCtxUntracksOnCancel(t *testing.T) {
+	db := Create()
+	db.EnableDeadlockDetection(DeadlockConfig{})
+
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go db.Update(func(tx *Tx) error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := db.BeginCtx(ctx, true)
+	if err == nil {
+		t.Fatalf("expected BeginCtx to be cancelled")
+	}
+
+	gid := goroutineID()
+	tracker.mu.Lock()
+	held2 := len(tracker.holders[gid])
+	tracker.mu.Unlock()
+	if held2 != 0 {
+		t.Fatalf("expected no phantom holders for this goroutine after cancellation, got %d", held2)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Cursor iterates a Tx's current bucket in key order. It snapshots the
+// bucket's keys when Cursor is called, so keys added through the same Tx
+// afterwards are not visited; values are looked up in the Tx as the
+// cursor reaches each key, so a later Set to an existing key is seen.
+type Cursor struct {
+	tx   *Tx
+	keys []string
+	pos  int
+}
+
+// Cursor returns a Cursor over tx's current bucket, sorted by key.
+func (tx *Tx) Cursor() *Cursor {
+	bucket := tx.snapshot[tx.bucket]
+	keys := make([]string, 0, len(bucket))
+	for k := range bucket {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &Cursor{tx: tx, keys: keys, pos: -1}
+}
+
+func (c *Cursor) item() (string, string, bool) {
+	if c.pos < 0 || c.pos >= len(c.keys) {
+		return "", "", false
+	}
+	k := c.keys[c.pos]
+	return k, c.tx.snapshot[c.tx.bucket][k], true
+}
+
+// First moves the cursor to the lowest key.
+func (c *Cursor) First() (string, string, bool) {
+	c.pos = 0
+	return c.item()
+}
+
+// Last moves the cursor to the highest key.
+func (c *Cursor) Last() (string, string, bool) {
+	c.pos = len(c.keys) - 1
+	return c.item()
+}
+
+// Next moves the cursor to the next key.
+func (c *Cursor) Next() (string, string, bool) {
+	c.pos++
+	return c.item()
+}
+
+// Prev moves the cursor to the previous key.
+func (c *Cursor) Prev() (string, string, bool) {
+	c.pos--
+	return c.item()
+}
+
+// Seek moves the cursor to the first key with the given prefix.
+func (c *Cursor) Seek(prefix string) (string, string, bool) {
+	idx := sort.Search(len(c.keys), func(i int) bool { return c.keys[i] >= prefix })
+	if idx < len(c.keys) && strings.HasPrefix(c.keys[idx], prefix) {
+		c.pos = idx
+		return c.item()
+	}
+	c.pos = len(c.keys)
+	return "", "", false
+}
+
+// lowerBound moves the cursor to the first key >= key, whether or not
+// key is a prefix of it. Unlike Seek, this is a plain lower-bound
+// lookup, which is what Range actually wants: start need not be a
+// prefix of any key in the range.
+func (c *Cursor) lowerBound(key string) (string, string, bool) {
+	c.pos = sort.Search(len(c.keys), func(i int) bool { return c.keys[i] >= key })
+	return c.item()
+}
+
+// ForEach calls fn for every key/value pair in tx's current bucket, in
+// key order, stopping at the first error fn returns.
+func (tx *Tx) ForEach(fn func(k, v string) error) error {
+	c := tx.Cursor()
+	for k, v, ok := c.First(); ok; k, v, ok = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range calls fn for every key k in [start, end) within tx's current
+// bucket, in key order.
+func (tx *Tx) Range(start, end string, fn func(k, v string) error) error {
+	c := tx.Cursor()
+	for k, v, ok := c.lowerBound(start); ok && k < end; k, v, ok = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
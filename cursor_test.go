@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func seedBucket(t *testing.T, db *DB, keys ...string) {
+	t.Helper()
+	err := db.Update(func(tx *Tx) error {
+		for _, k := range keys {
+			tx.Set(k, k)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+}
+
+// TestRangeNonPrefixStart reproduces Range silently yielding nothing
+// when start is a lower bound that is not a literal prefix of any key
+// in range: Range("bay", "c", ...) over ["bar", "baz"] must still visit
+// "baz", even though "baz" does not start with "bay".
+func TestRangeNonPrefixStart(t *testing.T) {
+	db := Create()
+	seedBucket(t, db, "bar", "baz")
+
+	var got []string
+	err := db.View(func(tx *Tx) error {
+		return tx.Range("bay", "c", func(k, v string) error {
+			got = append(got, k)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "baz" {
+		t.Fatalf("Range(\"bay\", \"c\") = %v, want [baz]", got)
+	}
+}
+
+// TestCursorSeekIsPrefixOnly documents Seek's existing prefix-match
+// contract, distinct from Range's lower-bound semantics.
+func TestCursorSeekIsPrefixOnly(t *testing.T) {
+	db := Create()
+	seedBucket(t, db, "bar", "baz")
+
+	err := db.View(func(tx *Tx) error {
+		c := tx.Cursor()
+		if _, _, ok := c.Seek("bay"); ok {
+			t.Fatalf("Seek(\"bay\") should find nothing: \"bay\" is not a prefix of any key")
+		}
+		k, _, ok := c.Seek("ba")
+		if !ok || k != "bar" {
+			t.Fatalf("Seek(\"ba\") = %q, %v; want \"bar\", true", k, ok)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeadlockConfig enables and tunes the opt-in deadlock detector installed
+// via DB.EnableDeadlockDetection. It adds no overhead at all when a DB's
+// deadlock field is left nil, which is the default.
+type DeadlockConfig struct {
+	// HeldTooLong is how long a Tx may hold its lock before
+	// OnHeldTooLong fires. Zero disables the check.
+	HeldTooLong time.Duration
+	// OnDeadlock is called with the lock-order cycle found when Begin
+	// would create one.
+	OnDeadlock func(DeadlockReport)
+	// OnHeldTooLong is called when a Tx is still holding its lock past
+	// HeldTooLong.
+	OnHeldTooLong func(HeldTooLongReport)
+}
+
+// DeadlockReport is a lock-order cycle: the goroutine acquiring the last
+// edge's To already holds (transitively) a lock that is waiting on it.
+type DeadlockReport struct {
+	Cycle []LockOrderEdge
+}
+
+// LockOrderEdge is one step of a detected cycle: some goroutine acquired
+// To while already holding From.
+type LockOrderEdge struct {
+	GoroutineID uint64
+	From, To    *DB
+	Stack       string
+}
+
+// HeldTooLongReport names the Tx (by its DB and mode) and the goroutine
+// that is still sitting on a lock past the configured threshold.
+type HeldTooLongReport struct {
+	GoroutineID uint64
+	DB          *DB
+	Writable    bool
+	Held        time.Duration
+	Stack       string
+}
+
+type heldLock struct {
+	db         *DB
+	writable   bool
+	acquiredAt time.Time
+	// edgesFrom is every other DB this goroutine already held when db
+	// was acquired; endTracking uses it to undo exactly the edges this
+	// hold contributed, so the graph only ever reflects locks that are
+	// actually held concurrently right now.
+	edgesFrom []*DB
+}
+
+// deadlockTracker is a package-level singleton: a lock-order cycle only
+// shows up across every DB a goroutine touches, not within a single one,
+// so the graph has to live above any individual *DB.
+type deadlockTracker struct {
+	mu      sync.Mutex
+	holders map[uint64][]heldLock // goroutine ID -> its currently held locks, in acquisition order
+	edges   map[*DB]map[*DB]int   // edges[a][b]: count of currently-held locks that acquired b while already holding a
+}
+
+var tracker = &deadlockTracker{
+	holders: make(map[uint64][]heldLock),
+	edges:   make(map[*DB]map[*DB]int),
+}
+
+// goroutineID parses the numeric ID out of runtime.Stack's header line
+// ("goroutine 123 [running]:"), since the runtime does not expose it
+// directly.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
+func callStack() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	var b bytes.Buffer
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// EnableDeadlockDetection turns on lock-order and held-too-long tracking
+// for db. Calling it again replaces the previous config.
+func (db *DB) EnableDeadlockDetection(cfg DeadlockConfig) {
+	db.deadlock = &cfg
+}
+
+// beginTracking records that the calling goroutine is about to lock db,
+// checks whether that closes a cycle in the global lock-order graph, and
+// reports it via cfg.OnDeadlock if so. It must be called before the real
+// mu.Lock() (a read-only Tx takes none) so the cycle is reported before
+// the goroutine can block on it.
+func (db *DB) beginTracking(writable bool) {
+	if db.deadlock == nil {
+		return
+	}
+	gid := goroutineID()
+	stack := callStack()
+
+	tracker.mu.Lock()
+	var edgesFrom []*DB
+	for _, h := range tracker.holders[gid] {
+		if h.db == db {
+			continue
+		}
+		if tracker.edges[h.db] == nil {
+			tracker.edges[h.db] = make(map[*DB]int)
+		}
+		tracker.edges[h.db][db]++
+		edgesFrom = append(edgesFrom, h.db)
+	}
+	cycle := tracker.findCycle(db)
+	tracker.holders[gid] = append(tracker.holders[gid], heldLock{db: db, writable: writable, acquiredAt: time.Now(), edgesFrom: edgesFrom})
+	tracker.mu.Unlock()
+
+	if cycle != nil && db.deadlock.OnDeadlock != nil {
+		for i := range cycle {
+			cycle[i].GoroutineID = gid
+			cycle[i].Stack = stack
+		}
+		db.deadlock.OnDeadlock(DeadlockReport{Cycle: cycle})
+	}
+
+	if db.deadlock.HeldTooLong > 0 {
+		go db.watchHeldTooLong(gid)
+	}
+}
+
+func (db *DB) endTracking() {
+	if db.deadlock == nil {
+		return
+	}
+	gid := goroutineID()
+	tracker.mu.Lock()
+	held := tracker.holders[gid]
+	for i, h := range held {
+		if h.db != db {
+			continue
+		}
+		// Undo exactly the edges this hold contributed, so a Tx that
+		// finished long ago can't combine with a later, never-
+		// concurrent ordering to report a false cycle.
+		for _, src := range h.edgesFrom {
+			if tracker.edges[src][db] > 1 {
+				tracker.edges[src][db]--
+			} else {
+				delete(tracker.edges[src], db)
+				if len(tracker.edges[src]) == 0 {
+					delete(tracker.edges, src)
+				}
+			}
+		}
+		tracker.holders[gid] = append(held[:i], held[i+1:]...)
+		break
+	}
+	if len(tracker.holders[gid]) == 0 {
+		delete(tracker.holders, gid)
+	}
+	tracker.mu.Unlock()
+}
+
+// watchHeldTooLong fires cfg.OnHeldTooLong once if the calling goroutine's
+// most recent lock on db is still held after HeldTooLong has elapsed.
+func (db *DB) watchHeldTooLong(gid uint64) {
+	timer := time.NewTimer(db.deadlock.HeldTooLong)
+	defer timer.Stop()
+	<-timer.C
+
+	tracker.mu.Lock()
+	var current *heldLock
+	for i, h := range tracker.holders[gid] {
+		if h.db == db {
+			current = &tracker.holders[gid][i]
+		}
+	}
+	tracker.mu.Unlock()
+
+	if current != nil && db.deadlock.OnHeldTooLong != nil {
+		db.deadlock.OnHeldTooLong(HeldTooLongReport{
+			GoroutineID: gid,
+			DB:          db,
+			Writable:    current.writable,
+			Held:        time.Since(current.acquiredAt),
+			Stack:       callStack(),
+		})
+	}
+}
+
+// findCycle looks for a path that leaves start and comes back to it in
+// the lock-order graph: some other goroutine is already waiting on a lock
+// that this acquisition would depend on, in the opposite order.
+func (t *deadlockTracker) findCycle(start *DB) []LockOrderEdge {
+	visited := make(map[*DB]bool)
+	var path []LockOrderEdge
+	var walk func(node *DB) bool
+	walk = func(node *DB) bool {
+		if node == start && len(path) > 0 {
+			return true
+		}
+		if visited[node] {
+			return false
+		}
+		visited[node] = true
+		for next := range t.edges[node] {
+			path = append(path, LockOrderEdge{From: node, To: next})
+			if walk(next) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	if walk(start) {
+		return path
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+// TestDeadlockTrackerPrunesFinishedEdges reproduces a false positive
+// where edges recorded by a Tx that has already finished stuck around
+// forever and combined with a later, never-concurrent ordering to look
+// like a cycle.
+import "testing"
+
+func TestDeadlockTrackerPrunesFinishedEdges(t *testing.T) {
+	dbA := Create()
+	dbB := Create()
+
+	var reports int
+	cfg := DeadlockConfig{OnDeadlock: func(DeadlockReport) { reports++ }}
+	dbA.EnableDeadlockDetection(cfg)
+	dbB.EnableDeadlockDetection(cfg)
+
+	// A fully finished Tx sequence holding A then B, in the same
+	// goroutine, so the edge A->B is recorded and then released.
+	dbA.beginTracking(true)
+	dbB.beginTracking(true)
+	dbB.endTracking()
+	dbA.endTracking()
+
+	// A later, non-overlapping sequence holding B then A must not be
+	// flagged: the A->B edge from the earlier, finished hold should no
+	// longer exist to combine with this B->A edge into a false cycle.
+	dbB.beginTracking(true)
+	dbA.beginTracking(true)
+	dbA.endTracking()
+	dbB.endTracking()
+
+	if reports != 0 {
+		t.Fatalf("expected no deadlock reports from non-overlapping Tx sequences, got %d", reports)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultLockTimeout is used by Begin when no explicit LockOptions.Timeout
+// is supplied.
+const defaultLockTimeout = 5 * time.Second
+
+// Locker is a pluggable lock backend so a DB can coordinate access across
+// multiple nodes instead of relying solely on the in-process RWMutex.
+// GetLock blocks (retrying internally) until the lock is granted, the
+// timeout elapses, or the configured failure tolerance is exceeded. The
+// returned handle is opaque to the caller and must be passed back into
+// ReleaseLock unchanged; two overlapping GetLock calls (e.g. a read
+// racing a write) return distinct handles, so releasing one never
+// touches the other's peers.
+type Locker interface {
+	GetLock(writable bool, timeout time.Duration) (any, error)
+	ReleaseLock(handle any)
+}
+
+// LockOptions configures a single transaction's distributed locking
+// behaviour. The zero value means "use the locker's defaults".
+type LockOptions struct {
+	// Timeout bounds how long GetLock will keep retrying before giving up.
+	// Zero means defaultLockTimeout.
+	Timeout time.Duration
+}
+
+// LockPeer is one node participating in quorum locking. In this tutorial
+// it lives in-process, standing in for what would otherwise be a network
+// round trip to a remote lock server.
+type LockPeer struct {
+	Addr string
+
+	mu        sync.Mutex
+	heldUntil time.Time
+}
+
+// grant leases the peer to the caller for the given duration unless it is
+// already held by someone else, mirroring a remote lock server saying
+// "yes" or "no" to a lock request.
+func (p *LockPeer) grant(lease time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.heldUntil) {
+		return false
+	}
+	p.heldUntil = time.Now().Add(lease)
+	return true
+}
+
+// renew extends an already-held lease; it is a no-op if the lease expired
+// in the meantime, which is what lets a crashed holder's lock simply time
+// out instead of wedging the cluster.
+func (p *LockPeer) renew(lease time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.heldUntil) {
+		p.heldUntil = time.Now().Add(lease)
+	}
+}
+
+func (p *LockPeer) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.heldUntil = time.Time{}
+}
+
+// QuorumLocker implements dsync-style quorum locking across a fixed set of
+// peers: a write lock only succeeds once N/2+1 peers grant it, while a
+// read lock only needs a single peer since readers never conflict with
+// one another. Tolerance caps how many peer failures GetLock will absorb
+// before it gives up early instead of waiting out the full timeout.
+type QuorumLocker struct {
+	peers     []*LockPeer
+	tolerance int
+	lease     time.Duration
+}
+
+// quorumGrant is the handle GetLock returns: the peers this particular
+// acquisition won plus the stop channel for its own refresh goroutine.
+// Keeping it per-acquisition (instead of on QuorumLocker itself) is what
+// lets two overlapping GetLock calls be released independently.
+type quorumGrant struct {
+	peers []*LockPeer
+	stop  chan struct{}
+}
+
+// NewQuorumLocker builds a locker over peers, tolerating up to tolerance
+// peer failures per GetLock call. lease is how long a grant is held
+// before it must be refreshed.
+func NewQuorumLocker(peers []*LockPeer, tolerance int, lease time.Duration) *QuorumLocker {
+	return &QuorumLocker{
+		peers:     peers,
+		tolerance: tolerance,
+		lease:     lease,
+	}
+}
+
+func (q *QuorumLocker) quorum(writable bool) int {
+	if !writable {
+		return 1
+	}
+	return len(q.peers)/2 + 1
+}
+
+func (q *QuorumLocker) GetLock(writable bool, timeout time.Duration) (any, error) {
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	need := q.quorum(writable)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var granted []*LockPeer
+		failures := 0
+		// Scan every peer before giving up on this round: stopping at
+		// the first peer past the tolerance would abandon peers that
+		// were never even tried, even when trying them could still
+		// reach quorum (e.g. 2 peers, quorum 1, tolerance 0 — peer one
+		// busy must not stop peer two from being tried).
+		for _, p := range q.peers {
+			if len(granted) >= need {
+				break
+			}
+			if p.grant(q.lease) {
+				granted = append(granted, p)
+			} else {
+				failures++
+			}
+		}
+
+		if len(granted) >= need {
+			stop := make(chan struct{})
+			go q.refresh(granted, stop)
+			return &quorumGrant{peers: granted, stop: stop}, nil
+		}
+
+		for _, p := range granted {
+			p.release()
+		}
+
+		// A round that fails more peers than the configured tolerance
+		// is not worth retrying until the full timeout elapses: enough
+		// of the cluster is down that waiting out more 10ms rounds
+		// won't change the outcome, so give up on this GetLock call now.
+		if failures > q.tolerance {
+			return nil, fmt.Errorf("quorum lock: %d failures exceeds tolerance %d (%d/%d peers granted)", failures, q.tolerance, len(granted), need)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("quorum lock: only %d/%d peers granted (%d failures, tolerance %d)", len(granted), need, failures, q.tolerance)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (q *QuorumLocker) ReleaseLock(handle any) {
+	grant, ok := handle.(*quorumGrant)
+	if !ok || grant == nil {
+		return
+	}
+	close(grant.stop)
+	for _, p := range grant.peers {
+		p.release()
+	}
+}
+
+// refresh keeps a held lock's lease alive in the background so that a
+// long-running transaction is not evicted mid-flight, while still letting
+// the lease lapse (and thus the cluster recover) if the holder crashes
+// before calling ReleaseLock.
+func (q *QuorumLocker) refresh(peers []*LockPeer, stop chan struct{}) {
+	ticker := time.NewTicker(q.lease / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, p := range peers {
+				p.renew(q.lease)
+			}
+		}
+	}
+}
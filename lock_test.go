@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestQuorumLockerIndependentHandles reproduces the bug where two
+// overlapping GetLock calls shared QuorumLocker's instance fields:
+// releasing the first acquisition must not touch peers the second
+// acquisition is still holding, and vice versa.
+func TestQuorumLockerIndependentHandles(t *testing.T) {
+	peers := []*LockPeer{{Addr: "p1"}, {Addr: "p2"}}
+	locker := NewQuorumLocker(peers, 0, time.Minute)
+
+	// Read locks only need one peer each, so two reads can both win.
+	handleA, err := locker.GetLock(false, time.Second)
+	if err != nil {
+		t.Fatalf("GetLock A: %v", err)
+	}
+	handleB, err := locker.GetLock(false, time.Second)
+	if err != nil {
+		t.Fatalf("GetLock B: %v", err)
+	}
+
+	locker.ReleaseLock(handleA)
+
+	if peers[0].heldUntil.IsZero() == peers[1].heldUntil.IsZero() {
+		t.Fatalf("expected exactly one peer still held after releasing A, got p1.held=%v p2.held=%v",
+			!peers[0].heldUntil.IsZero(), !peers[1].heldUntil.IsZero())
+	}
+
+	locker.ReleaseLock(handleB)
+	if !peers[0].heldUntil.IsZero() || !peers[1].heldUntil.IsZero() {
+		t.Fatalf("expected both peers free after releasing both handles")
+	}
+}
+
+// TestQuorumLockerScansAllPeers ensures a busy peer early in the list
+// does not abort the round before later peers that could still satisfy
+// quorum (2 of 3) are tried, even with zero tolerance for failures.
+func TestQuorumLockerScansAllPeers(t *testing.T) {
+	busy := &LockPeer{Addr: "busy"}
+	busy.heldUntil = time.Now().Add(time.Minute)
+	free1 := &LockPeer{Addr: "free1"}
+	free2 := &LockPeer{Addr: "free2"}
+
+	locker := NewQuorumLocker([]*LockPeer{busy, free1, free2}, 0, time.Minute)
+
+	handle, err := locker.GetLock(true, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected the two free peers to satisfy quorum, got: %v", err)
+	}
+	locker.ReleaseLock(handle)
+}
+
+// TestQuorumLockerFailsFastPastTolerance ensures a round with more peer
+// failures than the configured tolerance gives up immediately instead of
+// retrying every 10ms until the full timeout elapses.
+func TestQuorumLockerFailsFastPastTolerance(t *testing.T) {
+	busy1 := &LockPeer{Addr: "busy1"}
+	busy1.heldUntil = time.Now().Add(time.Minute)
+	busy2 := &LockPeer{Addr: "busy2"}
+	busy2.heldUntil = time.Now().Add(time.Minute)
+	busy3 := &LockPeer{Addr: "busy3"}
+	busy3.heldUntil = time.Now().Add(time.Minute)
+
+	// 3 peers, all down, zero tolerance: the very first round already
+	// has 3 failures > tolerance, so GetLock must not wait out the full
+	// timeout before reporting failure.
+	locker := NewQuorumLocker([]*LockPeer{busy1, busy2, busy3}, 0, time.Minute)
+
+	const timeout = 2 * time.Second
+	start := time.Now()
+	_, err := locker.GetLock(true, timeout)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatalf("expected GetLock to fail with every peer down")
+	}
+	if elapsed >= timeout/2 {
+		t.Fatalf("expected GetLock to fail fast past tolerance, took %v against a %v timeout", elapsed, timeout)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// msgpackCodec is a minimal hand-rolled MessagePack implementation: just
+// enough of the format (nil, bool, 64-bit int/uint/float, str 32, array
+// 32, map 32) to round-trip the same values json/gob do, without pulling
+// in an external package for one more encoding.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v any) (string, error) {
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, reflect.ValueOf(v)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (msgpackCodec) Unmarshal(data string, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: decode target must be a non-nil pointer")
+	}
+	decoded, err := msgpackDecode(bytes.NewReader([]byte(data)))
+	if err != nil {
+		return err
+	}
+	return msgpackAssign(rv.Elem(), decoded)
+}
+
+// The format codes this codec actually emits. Real MessagePack has
+// compact forms (fixint, fixstr, ...) for small values; this tutorial
+// implementation always uses the fixed-width 32/64-bit forms instead,
+// trading a few bytes for a much smaller encoder/decoder.
+const (
+	mpNil    byte = 0xc0
+	mpFalse  byte = 0xc2
+	mpTrue   byte = 0xc3
+	mpFloat  byte = 0xcb
+	mpUint64 byte = 0xcf
+	mpInt64  byte = 0xd3
+	mpStr32  byte = 0xdb
+	mpArr32  byte = 0xdd
+	mpMap32  byte = 0xdf
+)
+
+func msgpackEncode(w *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		w.WriteByte(mpNil)
+		return nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			w.WriteByte(mpNil)
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			w.WriteByte(mpTrue)
+		} else {
+			w.WriteByte(mpFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.WriteByte(mpInt64)
+		return binary.Write(w, binary.BigEndian, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		w.WriteByte(mpUint64)
+		return binary.Write(w, binary.BigEndian, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		w.WriteByte(mpFloat)
+		return binary.Write(w, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		s := v.String()
+		w.WriteByte(mpStr32)
+		binary.Write(w, binary.BigEndian, uint32(len(s)))
+		w.WriteString(s)
+	case reflect.Slice, reflect.Array:
+		n := v.Len()
+		w.WriteByte(mpArr32)
+		binary.Write(w, binary.BigEndian, uint32(n))
+		for i := 0; i < n; i++ {
+			if err := msgpackEncode(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		w.WriteByte(mpMap32)
+		binary.Write(w, binary.BigEndian, uint32(len(keys)))
+		for _, k := range keys {
+			if err := msgpackEncode(w, k); err != nil {
+				return err
+			}
+			if err := msgpackEncode(w, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		var fields []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				fields = append(fields, t.Field(i))
+			}
+		}
+		w.WriteByte(mpMap32)
+		binary.Write(w, binary.BigEndian, uint32(len(fields)))
+		for _, f := range fields {
+			if err := msgpackEncode(w, reflect.ValueOf(f.Name)); err != nil {
+				return err
+			}
+			if err := msgpackEncode(w, v.FieldByName(f.Name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Kind())
+	}
+	return nil
+}
+
+// msgpackMap is a decoded mpMap32 value: keys and values in encounter
+// order, each still in their own natural decoded type. A plain
+// map[string]any can't represent this — msgpack map keys aren't
+// restricted to strings (e.g. a map[int]string), so collapsing every
+// key to its string form here would make decoding into such a map
+// either lossy or impossible.
+type msgpackMap struct {
+	keys   []any
+	values []any
+}
+
+// generic converts m into the same shape json.Unmarshal would produce
+// for an object: map[string]any when every key actually is a string
+// (the common case, including every struct's field-name map), or
+// map[any]any when it isn't.
+func (m *msgpackMap) generic() any {
+	for _, k := range m.keys {
+		if _, ok := k.(string); !ok {
+			out := make(map[any]any, len(m.keys))
+			for i, k := range m.keys {
+				out[k] = m.values[i]
+			}
+			return out
+		}
+	}
+	out := make(map[string]any, len(m.keys))
+	for i, k := range m.keys {
+		out[k.(string)] = m.values[i]
+	}
+	return out
+}
+
+// msgpackDecode reads one value into its natural Go representation
+// (nil, bool, int64, uint64, float64, string, []any, *msgpackMap), the
+// same shape json.Unmarshal produces into an interface{} except that
+// msgpack maps keep their original key type until msgpackAssign knows
+// what it's decoding into.
+func msgpackDecode(r *bytes.Reader) (any, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case mpNil:
+		return nil, nil
+	case mpFalse:
+		return false, nil
+	case mpTrue:
+		return true, nil
+	case mpInt64:
+		var n int64
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case mpUint64:
+		var n uint64
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case mpFloat:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case mpStr32:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	case mpArr32:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i := range out {
+			v, err := msgpackDecode(r)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case mpMap32:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		out := &msgpackMap{keys: make([]any, n), values: make([]any, n)}
+		for i := uint32(0); i < n; i++ {
+			k, err := msgpackDecode(r)
+			if err != nil {
+				return nil, err
+			}
+			v, err := msgpackDecode(r)
+			if err != nil {
+				return nil, err
+			}
+			out.keys[i] = k
+			out.values[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unknown tag 0x%x", tag)
+	}
+}
+
+// msgpackAssign copies a value decoded by msgpackDecode into dst,
+// converting numeric kinds and recursing into slices, maps and structs
+// as needed, the same way json.Unmarshal would populate a typed target
+// from its generic decode tree.
+func msgpackAssign(dst reflect.Value, src any) error {
+	if src == nil {
+		return nil
+	}
+	if dst.Kind() == reflect.Interface {
+		if m, ok := src.(*msgpackMap); ok {
+			dst.Set(reflect.ValueOf(m.generic()))
+			return nil
+		}
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+
+	switch dst.Kind() {
+	case reflect.Bool:
+		dst.SetBool(sv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch sv.Kind() {
+		case reflect.Int64:
+			dst.SetInt(sv.Int())
+		case reflect.Uint64:
+			dst.SetInt(int64(sv.Uint()))
+		case reflect.Float64:
+			dst.SetInt(int64(sv.Float()))
+		default:
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch sv.Kind() {
+		case reflect.Uint64:
+			dst.SetUint(sv.Uint())
+		case reflect.Int64:
+			dst.SetUint(uint64(sv.Int()))
+		case reflect.Float64:
+			dst.SetUint(uint64(sv.Float()))
+		default:
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch sv.Kind() {
+		case reflect.Float64:
+			dst.SetFloat(sv.Float())
+		case reflect.Int64:
+			dst.SetFloat(float64(sv.Int()))
+		case reflect.Uint64:
+			dst.SetFloat(float64(sv.Uint()))
+		default:
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("msgpack: cannot assign %T to string", src)
+		}
+		dst.SetString(s)
+	case reflect.Slice:
+		items, ok := src.([]any)
+		if !ok {
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+		out := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := msgpackAssign(out.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+	case reflect.Map:
+		m, ok := src.(*msgpackMap)
+		if !ok {
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+		keyType, elemType := dst.Type().Key(), dst.Type().Elem()
+		out := reflect.MakeMapWithSize(dst.Type(), len(m.keys))
+		for i, k := range m.keys {
+			kv := reflect.New(keyType).Elem()
+			if err := msgpackAssign(kv, k); err != nil {
+				return fmt.Errorf("msgpack: map key: %w", err)
+			}
+			ev := reflect.New(elemType).Elem()
+			if err := msgpackAssign(ev, m.values[i]); err != nil {
+				return err
+			}
+			out.SetMapIndex(kv, ev)
+		}
+		dst.Set(out)
+	case reflect.Struct:
+		m, ok := src.(*msgpackMap)
+		if !ok {
+			return fmt.Errorf("msgpack: cannot assign %T to %s", src, dst.Type())
+		}
+		for i, k := range m.keys {
+			name, ok := k.(string)
+			if !ok {
+				continue
+			}
+			f := dst.FieldByName(name)
+			if !f.IsValid() || !f.CanSet() {
+				continue
+			}
+			if err := msgpackAssign(f, m.values[i]); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return msgpackAssign(dst.Elem(), src)
+	default:
+		return fmt.Errorf("msgpack: unsupported target kind %s", dst.Kind())
+	}
+	return nil
+}
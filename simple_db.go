@@ -1,51 +1,127 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"fmt"
 	"time"
 )
 
 type DB struct {
-	mu sync.RWMutex
-	data map[string]string
+	mu sync.RWMutex // only guards the commit path now; readers work off their own snapshot
+	engine Engine
+	locker Locker
+	deadlock *DeadlockConfig
+	codec string
 }
 
 func Create() (*DB) {
 	db := &DB{
-		data : make(map[string]string),
+		engine: newMemEngine(),
+		codec: JSONCodec.Name(),
 	}
 	return db
 }
 
+// Close releases the DB's underlying engine, flushing and closing any
+// open file it holds.
+func (db *DB) Close() error {
+	return db.engine.Close()
+}
+
+// CreateWithLocker is like Create but has every Begin also acquire a
+// distributed lock through locker before touching the in-process mutex,
+// so writes are only visible once a quorum of peers has agreed to them.
+func CreateWithLocker(locker Locker) (*DB) {
+	db := Create()
+	db.locker = locker
+	return db
+}
+
 type Tx struct {
 	db *DB
 	writable bool
+	bucket string
+	snapshot map[string]map[string]string // taken at Begin; readers never see a later write
+	lockHandle any // opaque handle from db.locker.GetLock, threaded back into ReleaseLock
 }
 
-func (tx *Tx) lock() {
+func (tx *Tx) lock(opts LockOptions) error {
+	if tx.db.locker != nil {
+		handle, err := tx.db.locker.GetLock(tx.writable, effectiveTimeout(context.Background(), opts))
+		if err != nil {
+			return err
+		}
+		tx.lockHandle = handle
+	}
+	tx.db.beginTracking(tx.writable)
 	if tx.writable {
+		// Only writers serialize on the commit path; a View never takes
+		// this mutex at all, since its snapshot already makes it
+		// consistent on its own.
 		tx.db.mu.Lock()
-	} else {
-		tx.db.mu.RLock()
 	}
+	return nil
 }
 
 func (tx *Tx) unlock() {
 	if tx.writable {
 		tx.db.mu.Unlock()
-	} else {
-		tx.db.mu.RUnlock()
 	}
+	if tx.db.locker != nil {
+		tx.db.locker.ReleaseLock(tx.lockHandle)
+	}
+	tx.db.endTracking()
+}
+
+// Bucket scopes a Tx to the named namespace; subsequent Get/Set/Commit
+// calls on the returned Tx read and write keys under it instead of
+// defaultBucket. The bucket's map in the snapshot is ensured on first use
+// regardless of tx.writable — tx.snapshot is already a private per-Tx
+// copy, so a View touching a bucket name that doesn't exist yet just
+// sees (and, harmlessly, can Set into) an empty one instead of panicking
+// on a nil map; only a writable Tx's Commit ever persists it.
+func (tx *Tx) Bucket(name string) *Tx {
+	if _, ok := tx.snapshot[name]; !ok {
+		tx.snapshot[name] = make(map[string]string)
+	}
+	scoped := *tx
+	scoped.bucket = name
+	return &scoped
 }
 
 func (tx *Tx) Set(key, value string) {
 	fmt.Println("Setting value... " , key , value)
-	tx.db.data[key] = value
+	tx.snapshot[tx.bucket][key] = value
 }
 
 func (tx *Tx) Get(key string) string {
-	return tx.db.data[key]
+	return tx.snapshot[tx.bucket][key]
+}
+
+// Commit persists a writable Tx's snapshot through the engine and
+// releases its lock. Committing a read-only Tx is an error since it has
+// nothing to persist; call Rollback instead.
+func (tx *Tx) Commit() error {
+	if !tx.writable {
+		return fmt.Errorf("db: cannot commit a read-only tx")
+	}
+	fmt.Println("Committing...")
+	if err := tx.db.engine.Apply(tx.snapshot); err != nil {
+		return err
+	}
+	tx.unlock()
+	return nil
+}
+
+// Rollback discards a writable Tx's in-memory changes without
+// persisting them. On a read-only Tx it just releases the lock.
+func (tx *Tx) Rollback() error {
+	if tx.writable {
+		fmt.Println("Rolling back...")
+	}
+	tx.unlock()
+	return nil
 }
 
 func (db * DB) View(fn func (tx *Tx) error) error {
@@ -57,11 +133,26 @@ func (db * DB) Update(fn func (tx *Tx) error) error {
 }
 
 func (db *DB) Begin(writable bool) (*Tx,error) {
+	return db.BeginWithOptions(writable, LockOptions{})
+}
+
+// BeginWithOptions is like Begin but lets the caller tune the distributed
+// lock acquisition, e.g. how long to wait for quorum before giving up.
+// opts is ignored when the DB was not created with a Locker.
+func (db *DB) BeginWithOptions(writable bool, opts LockOptions) (*Tx, error) {
 	tx := &Tx {
 		db : db,
 		writable: writable,
+		bucket: defaultBucket,
+	}
+	if err := tx.lock(opts); err != nil {
+		return nil, err
+	}
+
+	tx.snapshot = db.engine.Snapshot()
+	if _, ok := tx.snapshot[defaultBucket]; !ok {
+		tx.snapshot[defaultBucket] = make(map[string]string)
 	}
-	tx.lock()
 
 	return tx,nil
 }
@@ -74,13 +165,11 @@ func (db *DB) managed(writable bool, fn func(tx *Tx) error) (err error) {
 	}
 
 	defer func() {
-		if writable {
-			fmt.Println("Write Unlocking...")
-			tx.unlock()
-		} else {
-			fmt.Println("Read Unlocking...")
-			tx.unlock()
+		if !writable || err != nil {
+			tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
 	err = fn(tx)
@@ -91,18 +180,36 @@ func main() {
 
 	db := Create()
 
-	go db.Update(func(tx *Tx) error {
-		tx.Set("mykey", "go")
-		tx.Set("mykey2", "is")
-		tx.Set("mykey3", "awesome")
-		return nil
-	})
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		db.Update(func(tx *Tx) error {
+			tx.Set("mykey", "go")
+			tx.Set("mykey2", "is")
+			tx.Set("mykey3", "awesome")
+			return nil
+		})
+	}()
 
-	go db.View(func(tx *Tx) error {
-		fmt.Println("value is")
-		fmt.Println(tx.Get("mykey3"))
-		return nil
-	})
+	go func() {
+		defer wg.Done()
+		// The View reads its own snapshot and never waits on the writer's
+		// mutex; past this timeout ViewCtx stops waiting for fn and
+		// returns ctx.Err().
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := db.ViewCtx(ctx, func(tx *Tx) error {
+			fmt.Println("value is")
+			fmt.Println(tx.Get("mykey3"))
+			return nil
+		})
+		if err != nil {
+			fmt.Println("view cancelled:", err)
+		}
+	}()
 
-	time.Sleep(20000000000)
+	wg.Wait()
 }
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+// TestViewBucketNewNameDoesNotPanic reproduces a panic where Bucket only
+// pre-created the bucket's map for a writable Tx, so calling
+// Bucket(newName).Set(...) inside a View indexed into a nil map.
+func TestViewBucketNewNameDoesNotPanic(t *testing.T) {
+	db := Create()
+
+	err := db.View(func(tx *Tx) error {
+		tx.Bucket("does-not-exist-yet").Set("k", "v")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+}